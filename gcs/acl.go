@@ -0,0 +1,263 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+
+	rawv1 "google.golang.org/api/storage/v1"
+)
+
+// ACLEntity identifies the scope an ACLRule grants access to: a user, a
+// group, a domain, a project team, or one of the special identities below.
+// Use the constructor functions to build one rather than assembling the
+// string by hand.
+type ACLEntity string
+
+const (
+	// AllUsers grants access to anyone, authenticated or not.
+	AllUsers ACLEntity = "allUsers"
+
+	// AllAuthenticatedUsers grants access to anyone authenticated with a
+	// Google account.
+	AllAuthenticatedUsers ACLEntity = "allAuthenticatedUsers"
+)
+
+// UserEntity returns the ACLEntity identifying the user with the given email
+// address.
+func UserEntity(email string) (ACLEntity, error) {
+	if email == "" {
+		return "", errors.New("gcs: user entity requires a non-empty email")
+	}
+	return ACLEntity("user-" + email), nil
+}
+
+// GroupEntity returns the ACLEntity identifying the Google group with the
+// given email address.
+func GroupEntity(email string) (ACLEntity, error) {
+	if email == "" {
+		return "", errors.New("gcs: group entity requires a non-empty email")
+	}
+	return ACLEntity("group-" + email), nil
+}
+
+// DomainEntity returns the ACLEntity identifying every user in the given
+// Google Apps domain.
+func DomainEntity(domain string) (ACLEntity, error) {
+	if domain == "" {
+		return "", errors.New("gcs: domain entity requires a non-empty domain")
+	}
+	return ACLEntity("domain-" + domain), nil
+}
+
+// ProjectEntity returns the ACLEntity identifying a project team, e.g. team
+// "owners" and projectNumber "123456789012".
+func ProjectEntity(team, projectNumber string) (ACLEntity, error) {
+	if team == "" || projectNumber == "" {
+		return "", errors.New("gcs: project entity requires both a team and a project number")
+	}
+	return ACLEntity(fmt.Sprintf("project-%s-%s", team, projectNumber)), nil
+}
+
+// ACLRole is the access level granted to an ACLEntity by an ACLRule.
+type ACLRole string
+
+const (
+	ACLRoleReader ACLRole = "READER"
+	ACLRoleWriter ACLRole = "WRITER"
+	ACLRoleOwner  ACLRole = "OWNER"
+)
+
+// ACLRule is a single entry in an access control list, granting Role to
+// Entity.
+type ACLRule struct {
+	Entity ACLEntity
+	Role   ACLRole
+}
+
+// ACLHandle manages the access control list for a single resource: a
+// bucket, a bucket's default object ACL, or a particular object. Obtain one
+// via Bucket.BucketACL, Bucket.DefaultObjectACL, or Bucket.ObjectACL.
+type ACLHandle interface {
+	// List returns the current set of ACL rules.
+	List(ctx context.Context) ([]ACLRule, error)
+
+	// Set grants role to entity, replacing any existing rule for that
+	// entity.
+	Set(ctx context.Context, entity ACLEntity, role ACLRole) error
+
+	// Delete removes any rule granting entity access.
+	Delete(ctx context.Context, entity ACLEntity) error
+}
+
+// aclScope identifies which of the three ACL resources an aclHandle talks
+// to.
+type aclScope int
+
+const (
+	aclScopeBucket aclScope = iota
+	aclScopeDefaultObject
+	aclScopeObject
+)
+
+type aclHandle struct {
+	bucket *bucket
+	scope  aclScope
+	object string // only meaningful when scope == aclScopeObject
+}
+
+func (b *bucket) BucketACL() ACLHandle {
+	return &aclHandle{bucket: b, scope: aclScopeBucket}
+}
+
+func (b *bucket) DefaultObjectACL() ACLHandle {
+	return &aclHandle{bucket: b, scope: aclScopeDefaultObject}
+}
+
+func (b *bucket) ObjectACL(name string) ACLHandle {
+	return &aclHandle{bucket: b, scope: aclScopeObject, object: name}
+}
+
+func (h *aclHandle) List(ctx context.Context) ([]ACLRule, error) {
+	service, err := h.bucket.rawService()
+	if err != nil {
+		return nil, fmt.Errorf("rawService: %v", err)
+	}
+
+	var rules []ACLRule
+	err = h.bucket.withRetry(ctx, true, func() error {
+		switch h.scope {
+		case aclScopeBucket:
+			raw, err := service.BucketAccessControls.List(h.bucket.name).Context(ctx).Do()
+			if err != nil {
+				return err
+			}
+			rules = make([]ACLRule, len(raw.Items))
+			for i, item := range raw.Items {
+				rules[i] = ACLRule{Entity: ACLEntity(item.Entity), Role: ACLRole(item.Role)}
+			}
+
+		case aclScopeDefaultObject:
+			raw, err := service.DefaultObjectAccessControls.List(h.bucket.name).Context(ctx).Do()
+			if err != nil {
+				return err
+			}
+			rules = make([]ACLRule, len(raw.Items))
+			for i, item := range raw.Items {
+				rules[i] = ACLRule{Entity: ACLEntity(item.Entity), Role: ACLRole(item.Role)}
+			}
+
+		case aclScopeObject:
+			raw, err := service.ObjectAccessControls.List(h.bucket.name, h.object).Context(ctx).Do()
+			if err != nil {
+				return err
+			}
+			rules = make([]ACLRule, len(raw.Items))
+			for i, item := range raw.Items {
+				rules[i] = ACLRule{Entity: ACLEntity(item.Entity), Role: ACLRole(item.Role)}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("List: %w", err)
+	}
+
+	return rules, nil
+}
+
+func (h *aclHandle) Set(ctx context.Context, entity ACLEntity, role ACLRole) error {
+	service, err := h.bucket.rawService()
+	if err != nil {
+		return fmt.Errorf("rawService: %v", err)
+	}
+
+	// Set is implemented as an upsert: try Update (which requires the rule to
+	// already exist), falling back to Insert if it doesn't. Repeating this
+	// whole sequence converges to the same state, so it is safe to retry.
+	err = h.bucket.withRetry(ctx, true, func() error {
+		switch h.scope {
+		case aclScopeBucket:
+			rule := &rawv1.BucketAccessControl{Entity: string(entity), Role: string(role)}
+			_, err := service.BucketAccessControls.Update(h.bucket.name, string(entity), rule).Context(ctx).Do()
+			if isNotFound(err) {
+				_, err = service.BucketAccessControls.Insert(h.bucket.name, rule).Context(ctx).Do()
+			}
+			return err
+
+		case aclScopeDefaultObject:
+			rule := &rawv1.ObjectAccessControl{Entity: string(entity), Role: string(role)}
+			_, err := service.DefaultObjectAccessControls.Update(h.bucket.name, string(entity), rule).Context(ctx).Do()
+			if isNotFound(err) {
+				_, err = service.DefaultObjectAccessControls.Insert(h.bucket.name, rule).Context(ctx).Do()
+			}
+			return err
+
+		case aclScopeObject:
+			rule := &rawv1.ObjectAccessControl{Entity: string(entity), Role: string(role)}
+			_, err := service.ObjectAccessControls.Update(h.bucket.name, h.object, string(entity), rule).Context(ctx).Do()
+			if isNotFound(err) {
+				_, err = service.ObjectAccessControls.Insert(h.bucket.name, h.object, rule).Context(ctx).Do()
+			}
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Set: %w", err)
+	}
+
+	return nil
+}
+
+func (h *aclHandle) Delete(ctx context.Context, entity ACLEntity) error {
+	service, err := h.bucket.rawService()
+	if err != nil {
+		return fmt.Errorf("rawService: %v", err)
+	}
+
+	err = h.bucket.withRetry(ctx, true, func() error {
+		switch h.scope {
+		case aclScopeBucket:
+			return service.BucketAccessControls.Delete(h.bucket.name, string(entity)).Context(ctx).Do()
+
+		case aclScopeDefaultObject:
+			return service.DefaultObjectAccessControls.Delete(h.bucket.name, string(entity)).Context(ctx).Do()
+
+		case aclScopeObject:
+			return service.ObjectAccessControls.Delete(h.bucket.name, h.object, string(entity)).Context(ctx).Do()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+
+	return nil
+}
+
+// isNotFound reports whether err is a googleapi.Error with a 404 status.
+func isNotFound(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusNotFound
+}
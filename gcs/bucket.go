@@ -4,20 +4,27 @@
 package gcs
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
 	"golang.org/x/net/context"
 	"google.golang.org/cloud"
 	"google.golang.org/cloud/storage"
+
+	rawv1 "google.golang.org/api/storage/v1"
 )
 
 // Bucket represents a GCS bucket, pre-bound with a bucket name and necessary
-// authorization information.
+// authorization information. Use NewBucket to construct one.
 //
 // Each method that may block accepts a context object that is used for
 // deadlines and cancellation. Users need not package authorization information
 // into the context object (using cloud.WithContext or similar).
+//
+// Network calls are retried according to the RetryPolicy supplied to
+// NewBucket; see its docs for which operations are safe to retry blindly.
 type Bucket interface {
 	Name() string
 
@@ -31,16 +38,82 @@ type Bucket interface {
 	// needed.
 	NewReader(ctx context.Context, objectName string) (io.ReadCloser, error)
 
+	// Create a reader for the contents described by req, which may cover the
+	// whole object or (via req.Offset and req.Length) a byte range within it.
+	// The caller must arrange for the reader to be closed when it is no
+	// longer needed.
+	ReadObject(ctx context.Context, req *ReadObjectRequest) (*ObjectReader, error)
+
 	// Return an ObjectWriter that can be used to create or overwrite an object
 	// with the given attributes. attrs.Name must be specified. Otherwise, nil-
 	// and zero-valud attributes are ignored.
 	NewWriter(ctx context.Context, attrs *storage.ObjectAttrs) (ObjectWriter, error)
+
+	// Concatenate the contents of req.Sources, in order, into a new object
+	// named req.DstName, without the caller needing to read or re-upload the
+	// source contents. See the notes on ComposeObjectsRequest for naming and
+	// precondition semantics.
+	ComposeObjects(ctx context.Context, req *ComposeObjectsRequest) (*Object, error)
+
+	// Copy an existing object to a new name, optionally in a different
+	// bucket, entirely on the server side. See the notes on CopyObjectRequest
+	// for precondition and metadata-override semantics.
+	CopyObject(ctx context.Context, req *CopyObjectRequest) (*Object, error)
+
+	// Create or overwrite an object according to req, reading its contents
+	// from req.Contents. See the notes on CreateObjectRequest for naming and
+	// precondition semantics.
+	CreateObject(ctx context.Context, req *CreateObjectRequest) (*Object, error)
+
+	// Delete the object with the given name. It is not an error if the
+	// object does not exist.
+	DeleteObject(ctx context.Context, name string) error
+
+	// Objects returns an iterator over the objects (and, if req.Delimiter is
+	// set, collapsed runs) matching req, fetching additional pages from GCS
+	// lazily as the iterator is consumed.
+	Objects(ctx context.Context, req *ListObjectsRequest) ObjectIterator
+
+	// SignedURL returns a URL for objectName that is valid for the method,
+	// expiry, and other constraints described by opts without the bearer
+	// needing any further credentials. See the notes on SignedURLOptions.
+	SignedURL(objectName string, opts *SignedURLOptions) (string, error)
+
+	// BucketACL returns a handle on the bucket's own access control list.
+	BucketACL() ACLHandle
+
+	// DefaultObjectACL returns a handle on the ACL applied to new objects
+	// created in this bucket that don't specify one of their own.
+	DefaultObjectACL() ACLHandle
+
+	// ObjectACL returns a handle on the access control list of the object
+	// with the given name.
+	ObjectACL(name string) ACLHandle
+
+	// IAM returns a handle on the bucket's IAM policy.
+	IAM() *IAMHandle
 }
 
 type bucket struct {
 	projID string
 	client *http.Client
 	name   string
+	retry  RetryPolicy
+}
+
+// NewBucket returns a Bucket bound to the given project, HTTP client, and
+// bucket name. If policy is nil, DefaultRetryPolicy is used.
+func NewBucket(projID string, client *http.Client, name string, policy *RetryPolicy) Bucket {
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+
+	return &bucket{
+		projID: projID,
+		client: client,
+		name:   name,
+		retry:  *policy,
+	}
 }
 
 func (b *bucket) Name() string {
@@ -48,13 +121,27 @@ func (b *bucket) Name() string {
 }
 
 func (b *bucket) ListObjects(ctx context.Context, query *storage.Query) (*storage.Objects, error) {
-	authContext := cloud.WithContext(ctx, b.projID, b.client)
-	return storage.ListObjects(authContext, b.name, query)
+	var listing *storage.Objects
+	err := b.withRetry(ctx, true, func() error {
+		authContext := cloud.WithContext(ctx, b.projID, b.client)
+		var err error
+		listing, err = storage.ListObjects(authContext, b.name, query)
+		return err
+	})
+
+	return listing, err
 }
 
 func (b *bucket) NewReader(ctx context.Context, objectName string) (io.ReadCloser, error) {
-	authContext := cloud.WithContext(ctx, b.projID, b.client)
-	return storage.NewReader(authContext, b.name, objectName)
+	var r io.ReadCloser
+	err := b.withRetry(ctx, true, func() error {
+		authContext := cloud.WithContext(ctx, b.projID, b.client)
+		var err error
+		r, err = storage.NewReader(authContext, b.name, objectName)
+		return err
+	})
+
+	return r, err
 }
 
 func (b *bucket) NewWriter(ctx context.Context, attrs *storage.ObjectAttrs) (ObjectWriter, error) {
@@ -65,4 +152,219 @@ func (b *bucket) NewWriter(ctx context.Context, attrs *storage.ObjectAttrs) (Obj
 	}
 
 	return w, nil
+}
+
+// rawService returns a raw JSON API service bound to the bucket's HTTP
+// client. Compose and copy have no equivalent in google.golang.org/cloud/storage,
+// so we must drop down to the generated client.
+func (b *bucket) rawService() (*rawv1.Service, error) {
+	return rawv1.New(b.client)
+}
+
+func (b *bucket) ComposeObjects(
+	ctx context.Context,
+	req *ComposeObjectsRequest) (*Object, error) {
+	if len(req.Sources) == 0 {
+		return nil, errors.New("ComposeObjects requires at least one source")
+	}
+
+	if len(req.Sources) > 32 {
+		return nil, errors.New("ComposeObjects accepts at most 32 sources")
+	}
+
+	service, err := b.rawService()
+	if err != nil {
+		return nil, fmt.Errorf("rawService: %v", err)
+	}
+
+	sources := make([]*rawv1.ComposeRequestSourceObjects, len(req.Sources))
+	for i, s := range req.Sources {
+		sources[i] = &rawv1.ComposeRequestSourceObjects{
+			Name:       s.Name,
+			Generation: s.Generation,
+		}
+	}
+
+	composeReq := &rawv1.ComposeRequest{
+		SourceObjects: sources,
+		Destination: &rawv1.Object{
+			Name:            req.DstName,
+			Bucket:          b.name,
+			ContentType:     req.ContentType,
+			ContentLanguage: req.ContentLanguage,
+			ContentEncoding: req.ContentEncoding,
+			CacheControl:    req.CacheControl,
+			Metadata:        req.Metadata,
+		},
+	}
+
+	call := service.Objects.Compose(b.name, req.DstName, composeReq)
+	call = call.Context(ctx)
+	if req.DstGenerationPrecondition != nil {
+		call = call.IfGenerationMatch(*req.DstGenerationPrecondition)
+	}
+
+	// Compose creates req.DstName from scratch, so it is only safe to retry
+	// blindly when a generation precondition pins down exactly what state
+	// the destination must be in beforehand.
+	idempotent := req.DstGenerationPrecondition != nil
+
+	var raw *rawv1.Object
+	err = b.withRetry(ctx, idempotent, func() error {
+		var err error
+		raw, err = call.Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Compose: %w", err)
+	}
+
+	return toObject(raw)
+}
+
+func (b *bucket) CopyObject(
+	ctx context.Context,
+	req *CopyObjectRequest) (*Object, error) {
+	service, err := b.rawService()
+	if err != nil {
+		return nil, fmt.Errorf("rawService: %v", err)
+	}
+
+	dstBucket := req.DstBucket
+	if dstBucket == "" {
+		dstBucket = b.name
+	}
+
+	overrides := &rawv1.Object{}
+	if req.ContentType != nil {
+		overrides.ContentType = *req.ContentType
+	}
+	if req.ContentLanguage != nil {
+		overrides.ContentLanguage = *req.ContentLanguage
+	}
+	if req.ContentEncoding != nil {
+		overrides.ContentEncoding = *req.ContentEncoding
+	}
+	if req.CacheControl != nil {
+		overrides.CacheControl = *req.CacheControl
+	}
+	if req.Metadata != nil {
+		overrides.Metadata = make(map[string]string)
+		for k, v := range req.Metadata {
+			if v != nil {
+				overrides.Metadata[k] = *v
+			}
+		}
+	}
+
+	call := service.Objects.Copy(
+		b.name,
+		req.SrcName,
+		dstBucket,
+		req.DstName,
+		overrides)
+	call = call.Context(ctx)
+
+	if req.SrcGeneration != 0 {
+		call = call.SourceGeneration(req.SrcGeneration)
+	}
+	if req.SrcGenerationPrecondition != nil {
+		call = call.IfSourceGenerationMatch(*req.SrcGenerationPrecondition)
+	}
+	if req.DstGenerationPrecondition != nil {
+		call = call.IfGenerationMatch(*req.DstGenerationPrecondition)
+	}
+
+	// Like Compose, Copy creates the destination from scratch, so it is only
+	// safe to retry blindly with a destination generation precondition.
+	idempotent := req.DstGenerationPrecondition != nil
+
+	var raw *rawv1.Object
+	err = b.withRetry(ctx, idempotent, func() error {
+		var err error
+		raw, err = call.Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Copy: %w", err)
+	}
+
+	return toObject(raw)
+}
+
+func (b *bucket) CreateObject(
+	ctx context.Context,
+	req *CreateObjectRequest) (*Object, error) {
+	service, err := b.rawService()
+	if err != nil {
+		return nil, fmt.Errorf("rawService: %v", err)
+	}
+
+	object := &rawv1.Object{
+		Name:            req.Name,
+		Bucket:          b.name,
+		ContentType:     req.ContentType,
+		ContentLanguage: req.ContentLanguage,
+		ContentEncoding: req.ContentEncoding,
+		CacheControl:    req.CacheControl,
+		Metadata:        req.Metadata,
+	}
+
+	call := service.Objects.Insert(b.name, object)
+	call = call.Context(ctx).Media(req.Contents)
+	if req.GenerationPrecondition != nil {
+		call = call.IfGenerationMatch(*req.GenerationPrecondition)
+	}
+
+	// A generation precondition only makes the write itself safe to repeat
+	// server-side; it says nothing about req.Contents, which call.Do streams
+	// from as bytes go over the wire, so a transient failure partway through
+	// leaves it partially drained. A retry is only safe to attempt when the
+	// body can also be rewound and replayed, which requires it to be an
+	// io.Seeker (or the caller has opted in via RetryPolicy.AlwaysRetry).
+	seeker, seekable := req.Contents.(io.Seeker)
+	idempotent := req.GenerationPrecondition != nil && seekable
+
+	var raw *rawv1.Object
+	err = b.withRetry(ctx, idempotent, func() error {
+		if seekable {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		raw, err = call.Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Insert: %w", err)
+	}
+
+	return toObject(raw)
+}
+
+func (b *bucket) DeleteObject(ctx context.Context, name string) error {
+	service, err := b.rawService()
+	if err != nil {
+		return fmt.Errorf("rawService: %v", err)
+	}
+
+	call := service.Objects.Delete(b.name, name)
+	call = call.Context(ctx)
+
+	// Deleting by name with no generation precondition is inherently
+	// idempotent: a retry that lands after the first attempt already
+	// succeeded just sees a 404, which is treated as success below.
+	err = b.withRetry(ctx, true, func() error {
+		return call.Do()
+	})
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,135 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	rawv1 "google.golang.org/api/storage/v1"
+)
+
+// Binding grants Role to each of Members, where a member is a string like
+// "user:jane@example.com", "group:admins@example.com",
+// "serviceAccount:app@project.iam.gserviceaccount.com", "allUsers", or
+// "allAuthenticatedUsers".
+type Binding struct {
+	Role    string
+	Members []string
+}
+
+// Policy is a bucket's IAM policy: the set of role bindings in effect, along
+// with an Etag used for optimistic concurrency on SetPolicy.
+type Policy struct {
+	// Opaque, server-assigned. SetPolicy fails with an error if Etag does not
+	// match the policy's current value, so that concurrent read-modify-write
+	// sequences don't silently clobber one another.
+	Etag string
+
+	Bindings []Binding
+}
+
+// IAMHandle manages the IAM policy for a single bucket. Obtain one via
+// Bucket.IAM.
+type IAMHandle struct {
+	bucket *bucket
+}
+
+func (b *bucket) IAM() *IAMHandle {
+	return &IAMHandle{bucket: b}
+}
+
+func (h *IAMHandle) Policy(ctx context.Context) (*Policy, error) {
+	service, err := h.bucket.rawService()
+	if err != nil {
+		return nil, fmt.Errorf("rawService: %v", err)
+	}
+
+	var raw *rawv1.Policy
+	err = h.bucket.withRetry(ctx, true, func() error {
+		var err error
+		raw, err = service.Buckets.GetIamPolicy(h.bucket.name).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetIamPolicy: %w", err)
+	}
+
+	return toPolicy(raw), nil
+}
+
+func (h *IAMHandle) SetPolicy(ctx context.Context, p *Policy) error {
+	if p.Etag == "" {
+		return errors.New("gcs: SetPolicy requires a Policy obtained from Policy, to enforce optimistic concurrency")
+	}
+
+	service, err := h.bucket.rawService()
+	if err != nil {
+		return fmt.Errorf("rawService: %v", err)
+	}
+
+	raw := &rawv1.Policy{
+		Etag: p.Etag,
+	}
+	for _, b := range p.Bindings {
+		raw.Bindings = append(raw.Bindings, &rawv1.PolicyBindings{
+			Role:    b.Role,
+			Members: b.Members,
+		})
+	}
+
+	// The Etag pins this call to a single prior policy version, the same way
+	// a generation precondition pins Compose/Copy: a retry after a
+	// successful-but-unacknowledged write fails with a conflict rather than
+	// silently double-applying, so it is safe to retry blindly.
+	err = h.bucket.withRetry(ctx, true, func() error {
+		_, err := service.Buckets.SetIamPolicy(h.bucket.name, raw).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("SetIamPolicy: %w", err)
+	}
+
+	return nil
+}
+
+func (h *IAMHandle) TestPermissions(ctx context.Context, permissions []string) ([]string, error) {
+	service, err := h.bucket.rawService()
+	if err != nil {
+		return nil, fmt.Errorf("rawService: %v", err)
+	}
+
+	var raw *rawv1.TestIamPermissionsResponse
+	err = h.bucket.withRetry(ctx, true, func() error {
+		var err error
+		raw, err = service.Buckets.TestIamPermissions(h.bucket.name, permissions).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("TestIamPermissions: %w", err)
+	}
+
+	return raw.Permissions, nil
+}
+
+func toPolicy(raw *rawv1.Policy) *Policy {
+	p := &Policy{Etag: raw.Etag}
+	for _, b := range raw.Bindings {
+		p.Bindings = append(p.Bindings, Binding{Role: b.Role, Members: b.Members})
+	}
+	return p
+}
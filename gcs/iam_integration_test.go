@@ -0,0 +1,87 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build integration
+
+package gcs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// TestIAM_GrantAllUsersObjectViewer exercises a full round trip against a
+// real bucket: it creates an object, grants roles/storage.objectViewer to
+// allUsers via IAM, and confirms the object becomes readable through an
+// entirely unauthenticated client. It is skipped unless GCS_TEST_BUCKET and
+// GCS_TEST_PROJECT are set, since it requires a real bucket the caller owns.
+func TestIAM_GrantAllUsersObjectViewer(t *testing.T) {
+	bucketName := os.Getenv("GCS_TEST_BUCKET")
+	projID := os.Getenv("GCS_TEST_PROJECT")
+	if bucketName == "" || projID == "" {
+		t.Skip("GCS_TEST_BUCKET and GCS_TEST_PROJECT must be set to run this test")
+	}
+
+	ctx := context.Background()
+	client := http.DefaultClient
+	b := NewBucket(projID, client, bucketName, nil)
+
+	const objectName = "gcs-iam-integration-test-object"
+	const contents = "hello, public world"
+
+	if _, err := b.CreateObject(ctx, &CreateObjectRequest{
+		Name:     objectName,
+		Contents: strings.NewReader(contents),
+	}); err != nil {
+		t.Fatalf("CreateObject: %v", err)
+	}
+	defer b.DeleteObject(context.Background(), objectName)
+
+	policy, err := b.IAM().Policy(ctx)
+	if err != nil {
+		t.Fatalf("Policy: %v", err)
+	}
+
+	policy.Bindings = append(policy.Bindings, Binding{
+		Role:    "roles/storage.objectViewer",
+		Members: []string{"allUsers"},
+	})
+
+	if err := b.IAM().SetPolicy(ctx, policy); err != nil {
+		t.Fatalf("SetPolicy: %v", err)
+	}
+
+	// An entirely unauthenticated client must now be able to read the
+	// object's contents.
+	anonymous := NewBucket(projID, &http.Client{}, bucketName, nil)
+	r, err := anonymous.NewReader(ctx, objectName)
+	if err != nil {
+		t.Fatalf("NewReader (unauthenticated): %v", err)
+	}
+	defer r.Close()
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if string(got) != contents {
+		t.Errorf("got %q, want %q", got, contents)
+	}
+}
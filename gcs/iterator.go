@@ -0,0 +1,208 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"errors"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	rawv1 "google.golang.org/api/storage/v1"
+)
+
+// Done is returned by ObjectIterator.Next when there are no more objects to
+// return.
+var Done = errors.New("no more items in iterator")
+
+// IteratorPageInfo describes the state of an ObjectIterator's current page,
+// for callers that want to checkpoint and later resume a listing.
+type IteratorPageInfo struct {
+	// The number of items remaining to be returned from the page currently
+	// buffered in the iterator.
+	Remaining int
+
+	// The continuation token that will be used to fetch the next page, once
+	// the current one is exhausted. Empty if there are no more pages.
+	Token string
+}
+
+// ObjectIterator lazily lists the objects (and delimiter-collapsed runs) in
+// a bucket, fetching additional pages from GCS only as needed.
+//
+// An ObjectIterator is not safe for concurrent use.
+type ObjectIterator interface {
+	// Next returns the next object in the listing, interleaved with
+	// collapsed runs in the lexicographic order guaranteed by Listing, or
+	// Done if the listing is exhausted.
+	//
+	// Collapsed runs themselves are not returned by Next; use NextPage if you
+	// need them.
+	Next() (*Object, error)
+
+	// NextPage fetches and returns the next page of results directly,
+	// without the item-by-item interleaving that Next performs. It returns
+	// io.EOF-like Done behavior by way of returning two nil/empty slices and
+	// a nil error once the listing is exhausted.
+	//
+	// Callers should use either Next or NextPage exclusively for a given
+	// iterator; mixing the two styles on one iterator is not supported.
+	NextPage() (objects []*Object, collapsedRuns []string, err error)
+
+	// PageInfo describes the iterator's current page, for checkpointing.
+	PageInfo() IteratorPageInfo
+}
+
+// mergedEntry is a single item in the lexicographically-ordered stream that
+// Next produces, tagging whether it is an object or a collapsed run.
+type mergedEntry struct {
+	name   string
+	object *Object // nil for collapsed runs
+}
+
+type objectIterator struct {
+	ctx    context.Context
+	bucket *bucket
+	req    ListObjectsRequest
+
+	// The token to use for the next raw fetch. Initially req.ContinuationToken
+	// (often empty), and empty once exhausted; exhausted() distinguishes the
+	// two via fetchedOnce.
+	token       string
+	fetchedOnce bool
+
+	// Entries from the most recently fetched page that have not yet been
+	// returned by Next, in lexicographic order.
+	pending []mergedEntry
+}
+
+func (b *bucket) Objects(ctx context.Context, req *ListObjectsRequest) ObjectIterator {
+	reqCopy := *req
+	return &objectIterator{
+		ctx:    ctx,
+		bucket: b,
+		req:    reqCopy,
+		token:  req.ContinuationToken,
+	}
+}
+
+func (it *objectIterator) exhausted() bool {
+	return it.fetchedOnce && it.token == "" && len(it.pending) == 0
+}
+
+func (it *objectIterator) Next() (*Object, error) {
+	for len(it.pending) == 0 {
+		if it.exhausted() {
+			return nil, Done
+		}
+
+		objects, collapsedRuns, err := it.fetchPage()
+		if err != nil {
+			return nil, err
+		}
+
+		it.pending = mergeEntries(objects, collapsedRuns)
+	}
+
+	e := it.pending[0]
+	it.pending = it.pending[1:]
+
+	// Collapsed runs don't carry an Object; skip over them when the caller
+	// only wants objects.
+	if e.object == nil {
+		return it.Next()
+	}
+
+	return e.object, nil
+}
+
+func (it *objectIterator) NextPage() ([]*Object, []string, error) {
+	if it.exhausted() {
+		return nil, nil, nil
+	}
+
+	return it.fetchPage()
+}
+
+func (it *objectIterator) PageInfo() IteratorPageInfo {
+	return IteratorPageInfo{
+		Remaining: len(it.pending),
+		Token:     it.token,
+	}
+}
+
+// fetchPage fetches exactly one page from GCS, advancing it.token, and
+// returns its objects and collapsed runs.
+func (it *objectIterator) fetchPage() ([]*Object, []string, error) {
+	service, err := it.bucket.rawService()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	call := service.Objects.List(it.bucket.name)
+	call = call.Context(it.ctx)
+
+	if it.req.Prefix != "" {
+		call = call.Prefix(it.req.Prefix)
+	}
+	if it.req.Delimiter != "" {
+		call = call.Delimiter(it.req.Delimiter)
+	}
+	if it.token != "" {
+		call = call.PageToken(it.token)
+	}
+	if it.req.MaxResults != 0 {
+		call = call.MaxResults(int64(it.req.MaxResults))
+	}
+
+	var raw *rawv1.Objects
+	err = it.bucket.withRetry(it.ctx, true, func() error {
+		var err error
+		raw, err = call.Do()
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objects := make([]*Object, len(raw.Items))
+	for i, item := range raw.Items {
+		o, err := toObject(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		objects[i] = o
+	}
+
+	it.fetchedOnce = true
+	it.token = raw.NextPageToken
+
+	return objects, raw.Prefixes, nil
+}
+
+// mergeEntries interleaves objects and collapsedRuns into the single
+// lexicographic stream documented on Listing.
+func mergeEntries(objects []*Object, collapsedRuns []string) []mergedEntry {
+	entries := make([]mergedEntry, 0, len(objects)+len(collapsedRuns))
+	for _, o := range objects {
+		entries = append(entries, mergedEntry{name: o.Name, object: o})
+	}
+	for _, r := range collapsedRuns {
+		entries = append(entries, mergedEntry{name: r})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"testing"
+)
+
+func TestMergeEntries_InterleavesLexicographically(t *testing.T) {
+	objects := []*Object{
+		{Name: "b/1"},
+		{Name: "b/3"},
+		{Name: "c"},
+	}
+	collapsedRuns := []string{"a/", "b/2/"}
+
+	entries := mergeEntries(objects, collapsedRuns)
+
+	wantNames := []string{"a/", "b/1", "b/2/", "b/3", "c"}
+	if len(entries) != len(wantNames) {
+		t.Fatalf("len(entries) = %d, want %d (%v)", len(entries), len(wantNames), entries)
+	}
+	for i, want := range wantNames {
+		if entries[i].name != want {
+			t.Errorf("entries[%d].name = %q, want %q", i, entries[i].name, want)
+		}
+	}
+
+	// Collapsed runs must not carry an Object; objects must carry the exact
+	// pointer passed in.
+	for i, e := range entries {
+		switch wantNames[i] {
+		case "a/", "b/2/":
+			if e.object != nil {
+				t.Errorf("entries[%d] (%q) has a non-nil object, want nil for a collapsed run", i, e.name)
+			}
+		default:
+			if e.object == nil {
+				t.Errorf("entries[%d] (%q) has a nil object, want the source Object", i, e.name)
+			}
+		}
+	}
+}
+
+func TestMergeEntries_EqualNamesKeepOriginalOrder(t *testing.T) {
+	// mergeEntries uses a stable sort, so when an object and a collapsed run
+	// share a name (which GCS itself won't produce, but nothing here
+	// prevents it), the object - listed first in the call below - must sort
+	// ahead of the collapsed run.
+	objects := []*Object{{Name: "dup"}}
+	collapsedRuns := []string{"dup"}
+
+	entries := mergeEntries(objects, collapsedRuns)
+
+	if len(entries) != 2 {
+		t.Fatalf("len(entries) = %d, want 2", len(entries))
+	}
+	if entries[0].object == nil {
+		t.Errorf("entries[0] (the object passed first) should sort before the collapsed run with the same name")
+	}
+	if entries[1].object != nil {
+		t.Errorf("entries[1] should be the collapsed run")
+	}
+}
+
+func TestMergeEntries_EmptyInputs(t *testing.T) {
+	if entries := mergeEntries(nil, nil); len(entries) != 0 {
+		t.Errorf("mergeEntries(nil, nil) = %v, want empty", entries)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	rawv1 "google.golang.org/api/storage/v1"
+)
+
+// Object represents the metadata for a GCS object as returned by the GCS
+// JSON API, minus the parts that are purely internal to that API.
+type Object struct {
+	// The name of the object, not including the bucket.
+	Name string
+
+	// The generation and meta-generation of the object, as used for
+	// preconditions and for addressing a particular version of the object.
+	Generation     int64
+	MetaGeneration int64
+
+	// Information about the contents of the object. See the notes on
+	// CreateObjectRequest and UpdateObjectRequest for more details.
+	ContentType     string
+	ContentLanguage string
+	ContentEncoding string
+	CacheControl    string
+	Metadata        map[string]string
+
+	// The size of the object's contents, in bytes.
+	Size uint64
+
+	// Hashes of the object's contents, used for integrity checking by
+	// callers.
+	MD5    [md5.Size]byte
+	CRC32C uint32
+
+	// The time at which the object's metadata was last updated.
+	Updated time.Time
+}
+
+// toObject converts a raw JSON API representation of an object's metadata
+// into our own type.
+func toObject(o *rawv1.Object) (*Object, error) {
+	result := &Object{
+		Name:            o.Name,
+		Generation:      o.Generation,
+		MetaGeneration:  o.Metageneration,
+		ContentType:     o.ContentType,
+		ContentLanguage: o.ContentLanguage,
+		ContentEncoding: o.ContentEncoding,
+		CacheControl:    o.CacheControl,
+		Metadata:        o.Metadata,
+		Size:            o.Size,
+	}
+
+	if o.Md5Hash != "" {
+		raw, err := base64.StdEncoding.DecodeString(o.Md5Hash)
+		if err != nil {
+			return nil, fmt.Errorf("decoding MD5 hash: %v", err)
+		}
+
+		if len(raw) != len(result.MD5) {
+			return nil, fmt.Errorf("unexpected MD5 hash length: %d", len(raw))
+		}
+
+		copy(result.MD5[:], raw)
+	}
+
+	if o.Crc32c != "" {
+		raw, err := base64.StdEncoding.DecodeString(o.Crc32c)
+		if err != nil {
+			return nil, fmt.Errorf("decoding CRC32C: %v", err)
+		}
+
+		if len(raw) != 4 {
+			return nil, fmt.Errorf("unexpected CRC32C length: %d", len(raw))
+		}
+
+		result.CRC32C = binary.BigEndian.Uint32(raw)
+	}
+
+	if o.Updated != "" {
+		t, err := time.Parse(time.RFC3339, o.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Updated time: %v", err)
+		}
+
+		result.Updated = t
+	}
+
+	return result, nil
+}
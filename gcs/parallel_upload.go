@@ -0,0 +1,376 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// The default size of each part uploaded by ParallelUpload, in bytes.
+const DefaultPartSize = 32 << 20 // 32 MiB
+
+// The default number of parts that ParallelUpload will upload concurrently.
+const DefaultUploadConcurrency = 8
+
+// GCS compose accepts no more than this many source objects per call.
+const maxComposeSources = 32
+
+// A request to upload a large io.Reader to a single destination object by
+// sharding it into parts that are uploaded concurrently and then composed
+// together server-side. See ParallelUpload.
+type ParallelUploadRequest struct {
+	// The data to upload. ParallelUpload reads it to EOF.
+	Contents io.Reader
+
+	// The name with which to create the destination object.
+	DstName string
+
+	// A prefix under which to place temporary part and intermediate compose
+	// objects, e.g. ".tmp/<uuid>". Callers should make this unique per call so
+	// that concurrent uploads don't collide, and should ensure nothing else
+	// relies on names below it: ParallelUpload deletes everything it creates
+	// under this prefix before returning, including on error or cancellation.
+	TempPrefix string
+
+	// The size of each part to upload, in bytes. If zero, DefaultPartSize is
+	// used.
+	PartSize int64
+
+	// The number of parts to upload concurrently. If zero,
+	// DefaultUploadConcurrency is used.
+	Concurrency int
+
+	// Optional metadata for the destination object. See the notes on
+	// CreateObjectRequest for more details.
+	ContentType     string
+	ContentLanguage string
+	ContentEncoding string
+	CacheControl    string
+	Metadata        map[string]string
+
+	// If non-nil, the destination object will be created/overwritten only if
+	// its current generation matches this value. Like the metadata fields
+	// above, this precondition is applied only to the final compose call, not
+	// to any temporary object.
+	GenerationPrecondition *int64
+}
+
+// PartUploadError is returned by ParallelUpload when one of the concurrent
+// part uploads fails, so that callers can retry just the affected byte
+// range.
+type PartUploadError struct {
+	// The offset within the original Contents reader at which the failing
+	// part began.
+	Offset int64
+
+	// The underlying error returned by the failed upload.
+	Err error
+}
+
+func (e *PartUploadError) Error() string {
+	return fmt.Sprintf("uploading part at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *PartUploadError) Unwrap() error {
+	return e.Err
+}
+
+// ParallelUpload shards req.Contents into fixed-size parts, uploads them
+// concurrently as temporary objects under req.TempPrefix, and composes them
+// into a single destination object named req.DstName.
+//
+// Because GCS compose accepts no more than 32 source objects per call, parts
+// are composed in a tree: batches of up to 32 are composed into intermediate
+// objects, and those intermediates are recursively composed until no more
+// than 32 remain. Those are then composed into the destination by a final
+// compose call that carries req.GenerationPrecondition.
+//
+// ParallelUpload always attempts to clean up every temporary and
+// intermediate object it created, including when it returns an error or ctx
+// is cancelled.
+func ParallelUpload(
+	ctx context.Context,
+	bucket Bucket,
+	req *ParallelUploadRequest) (o *Object, err error) {
+	partSize := req.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultUploadConcurrency
+	}
+
+	// Track every temporary object we create so that we can clean it up
+	// unconditionally, regardless of how this function returns.
+	var tempNames []string
+	var tempNamesMu sync.Mutex
+	addTempName := func(name string) {
+		tempNamesMu.Lock()
+		tempNames = append(tempNames, name)
+		tempNamesMu.Unlock()
+	}
+
+	defer func() {
+		cleanupTempObjects(bucket, tempNames)
+	}()
+
+	sources, err := uploadParts(ctx, bucket, req, partSize, concurrency, addTempName)
+	if err != nil {
+		return nil, err
+	}
+
+	final, err := composeTree(ctx, bucket, req, sources, addTempName)
+	if err != nil {
+		return nil, fmt.Errorf("composeTree: %w", err)
+	}
+
+	return final, nil
+}
+
+// uploadParts shards req.Contents into parts of partSize bytes, uploading up
+// to concurrency of them at a time as temporary objects. It returns compose
+// sources in the same order as the original data.
+func uploadParts(
+	ctx context.Context,
+	bucket Bucket,
+	req *ParallelUploadRequest,
+	partSize int64,
+	concurrency int,
+	addTempName func(string)) ([]ComposeSource, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type part struct {
+		index  int
+		offset int64
+		data   []byte
+	}
+
+	type result struct {
+		index  int
+		source ComposeSource
+		err    *PartUploadError
+	}
+
+	var wg sync.WaitGroup
+	partCh := make(chan part)
+	resultCh := make(chan result)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range partCh {
+				name := fmt.Sprintf("%s/part-%04d", req.TempPrefix, p.index)
+
+				o, err := bucket.CreateObject(ctx, &CreateObjectRequest{
+					Name:     name,
+					Contents: bytes.NewReader(p.data),
+				})
+				if err != nil {
+					resultCh <- result{
+						index: p.index,
+						err:   &PartUploadError{Offset: p.offset, Err: err},
+					}
+					continue
+				}
+
+				addTempName(name)
+				resultCh <- result{
+					index: p.index,
+					source: ComposeSource{
+						Name:       o.Name,
+						Generation: o.Generation,
+					},
+				}
+			}
+		}()
+	}
+
+	// Feed parts to the workers on its own goroutine so that we can drain
+	// results concurrently without deadlocking on an unbuffered channel.
+	readErrCh := make(chan error, 1)
+	go func() {
+		defer close(partCh)
+
+		buf := make([]byte, partSize)
+		var offset int64
+		for index := 0; ; index++ {
+			n, err := io.ReadFull(req.Contents, buf)
+			if n > 0 {
+				data := make([]byte, n)
+				copy(data, buf[:n])
+
+				select {
+				case partCh <- part{index: index, offset: offset, data: data}:
+				case <-ctx.Done():
+					readErrCh <- ctx.Err()
+					return
+				}
+
+				offset += int64(n)
+			}
+
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				readErrCh <- nil
+				return
+			}
+			if err != nil {
+				readErrCh <- fmt.Errorf("reading contents: %v", err)
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	// selfCancelled tracks whether we cancelled ctx ourselves in response to a
+	// part failure, as opposed to the caller's ctx being cancelled out from
+	// under us. Only the goroutine running this loop ever sets it, and it is
+	// only read afterward in this same goroutine, so no synchronization is
+	// needed.
+	selfCancelled := false
+
+	results := make([]result, 0)
+	for r := range resultCh {
+		results = append(results, r)
+		if r.err != nil {
+			selfCancelled = true
+			cancel()
+		}
+	}
+
+	if readErr := <-readErrCh; readErr != nil {
+		if readErr != context.Canceled || !selfCancelled {
+			// Either a genuine read error, or ctx was cancelled by the
+			// caller rather than by us reacting to a part failure: in both
+			// cases there may be in-flight parts we never recorded a result
+			// for, so we can't trust results to be complete.
+			if readErr == context.Canceled {
+				return nil, ctx.Err()
+			}
+			return nil, readErr
+		}
+	}
+
+	// Surface the first real failure, preferring one whose error is not
+	// context.Canceled over one that is: when a part fails, cancel() stops
+	// the rest via the shared ctx, so other in-flight parts can come back
+	// reporting context.Canceled as collateral damage rather than their own
+	// failure. Reporting one of those instead of the real failure would
+	// point a caller retrying "just the affected byte range" at the wrong
+	// offset. If every failure is collateral cancellation, fall back to the
+	// lowest index for determinism.
+	sort.Slice(results, func(i, j int) bool { return results[i].index < results[j].index })
+
+	var failure *PartUploadError
+	for _, r := range results {
+		if r.err == nil {
+			continue
+		}
+		if failure == nil || (errors.Is(failure.Err, context.Canceled) && !errors.Is(r.err.Err, context.Canceled)) {
+			failure = r.err
+		}
+	}
+	if failure != nil {
+		return nil, failure
+	}
+
+	sources := make([]ComposeSource, 0, len(results))
+	for _, r := range results {
+		sources = append(sources, r.source)
+	}
+
+	return sources, nil
+}
+
+// composeTree composes sources into req.DstName, respecting the 32-source
+// limit on a single compose call by building intermediate objects as needed.
+func composeTree(
+	ctx context.Context,
+	bucket Bucket,
+	req *ParallelUploadRequest,
+	sources []ComposeSource,
+	addTempName func(string)) (*Object, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("ParallelUpload requires a non-empty Contents reader")
+	}
+
+	level := 0
+	for len(sources) > maxComposeSources {
+		var next []ComposeSource
+		for batchStart := 0; batchStart < len(sources); batchStart += maxComposeSources {
+			batchEnd := batchStart + maxComposeSources
+			if batchEnd > len(sources) {
+				batchEnd = len(sources)
+			}
+
+			name := fmt.Sprintf(
+				"%s/merge-%d-%04d",
+				req.TempPrefix,
+				level,
+				batchStart/maxComposeSources)
+
+			o, err := bucket.ComposeObjects(ctx, &ComposeObjectsRequest{
+				Sources: sources[batchStart:batchEnd],
+				DstName: name,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("intermediate compose: %w", err)
+			}
+
+			addTempName(name)
+			next = append(next, ComposeSource{Name: o.Name, Generation: o.Generation})
+		}
+
+		sources = next
+		level++
+	}
+
+	return bucket.ComposeObjects(ctx, &ComposeObjectsRequest{
+		Sources:                   sources,
+		DstName:                   req.DstName,
+		ContentType:               req.ContentType,
+		ContentLanguage:           req.ContentLanguage,
+		ContentEncoding:           req.ContentEncoding,
+		CacheControl:              req.CacheControl,
+		Metadata:                  req.Metadata,
+		DstGenerationPrecondition: req.GenerationPrecondition,
+	})
+}
+
+// cleanupTempObjects deletes every object named in names, best-effort. It
+// uses a fresh context so that cleanup still happens after the caller's
+// context has been cancelled.
+func cleanupTempObjects(bucket Bucket, names []string) {
+	for _, name := range names {
+		// Deliberately ignore errors: cleanup is best-effort, and the
+		// underlying objects are harmless litter under the caller's temp
+		// prefix if a delete fails.
+		_ = bucket.DeleteObject(context.Background(), name)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/cloud/storage"
+)
+
+// fakeObject is the in-memory state fakeBucket keeps for a single object.
+type fakeObject struct {
+	generation int64
+	data       []byte
+}
+
+// fakeBucket is a minimal, in-memory Bucket implementation covering just
+// enough of the interface to exercise ParallelUpload: CreateObject,
+// ComposeObjects, DeleteObject, and Name. Every other method panics, so a
+// test that accidentally exercises one fails loudly instead of silently
+// returning zero values.
+type fakeBucket struct {
+	mu sync.Mutex
+
+	objects        map[string]fakeObject
+	deleted        []string
+	nextGeneration int64
+
+	// If non-nil, called with each object name passed to CreateObject;
+	// returning a non-nil error simulates that part's upload failing
+	// instead of creating the object.
+	failCreate func(name string) error
+}
+
+func newFakeBucket() *fakeBucket {
+	return &fakeBucket{objects: make(map[string]fakeObject)}
+}
+
+func (b *fakeBucket) Name() string { return "fake-bucket" }
+
+func (b *fakeBucket) CreateObject(ctx context.Context, req *CreateObjectRequest) (*Object, error) {
+	if b.failCreate != nil {
+		if err := b.failCreate(req.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := ioutil.ReadAll(req.Contents)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextGeneration++
+	gen := b.nextGeneration
+	b.objects[req.Name] = fakeObject{generation: gen, data: data}
+
+	return &Object{Name: req.Name, Generation: gen, Size: uint64(len(data))}, nil
+}
+
+func (b *fakeBucket) ComposeObjects(ctx context.Context, req *ComposeObjectsRequest) (*Object, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(req.Sources) == 0 {
+		return nil, errors.New("fakeBucket: ComposeObjects requires at least one source")
+	}
+	if len(req.Sources) > 32 {
+		return nil, fmt.Errorf("fakeBucket: ComposeObjects got %d sources, want <= 32", len(req.Sources))
+	}
+
+	var data []byte
+	for _, s := range req.Sources {
+		o, ok := b.objects[s.Name]
+		if !ok {
+			return nil, fmt.Errorf("fakeBucket: compose source %q does not exist", s.Name)
+		}
+		if s.Generation != 0 && s.Generation != o.generation {
+			return nil, fmt.Errorf("fakeBucket: compose source %q generation mismatch", s.Name)
+		}
+		data = append(data, o.data...)
+	}
+
+	b.nextGeneration++
+	gen := b.nextGeneration
+	b.objects[req.DstName] = fakeObject{generation: gen, data: data}
+
+	return &Object{Name: req.DstName, Generation: gen, Size: uint64(len(data))}, nil
+}
+
+func (b *fakeBucket) DeleteObject(ctx context.Context, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.deleted = append(b.deleted, name)
+	delete(b.objects, name)
+	return nil
+}
+
+func (b *fakeBucket) data(name string) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.objects[name]
+	return o.data, ok
+}
+
+func (b *fakeBucket) names() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.objects))
+	for name := range b.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (b *fakeBucket) ListObjects(ctx context.Context, query *storage.Query) (*storage.Objects, error) {
+	panic("not implemented in fakeBucket")
+}
+
+func (b *fakeBucket) NewReader(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	panic("not implemented in fakeBucket")
+}
+
+func (b *fakeBucket) ReadObject(ctx context.Context, req *ReadObjectRequest) (*ObjectReader, error) {
+	panic("not implemented in fakeBucket")
+}
+
+func (b *fakeBucket) NewWriter(ctx context.Context, attrs *storage.ObjectAttrs) (ObjectWriter, error) {
+	panic("not implemented in fakeBucket")
+}
+
+func (b *fakeBucket) CopyObject(ctx context.Context, req *CopyObjectRequest) (*Object, error) {
+	panic("not implemented in fakeBucket")
+}
+
+func (b *fakeBucket) Objects(ctx context.Context, req *ListObjectsRequest) ObjectIterator {
+	panic("not implemented in fakeBucket")
+}
+
+func (b *fakeBucket) SignedURL(objectName string, opts *SignedURLOptions) (string, error) {
+	panic("not implemented in fakeBucket")
+}
+
+func (b *fakeBucket) BucketACL() ACLHandle { panic("not implemented in fakeBucket") }
+
+func (b *fakeBucket) DefaultObjectACL() ACLHandle { panic("not implemented in fakeBucket") }
+
+func (b *fakeBucket) ObjectACL(name string) ACLHandle { panic("not implemented in fakeBucket") }
+
+func (b *fakeBucket) IAM() *IAMHandle { panic("not implemented in fakeBucket") }
+
+func TestParallelUpload_Success(t *testing.T) {
+	b := newFakeBucket()
+
+	const partSize = 4
+	contents := []byte("0123456789abcdef01") // 18 bytes -> 5 parts, last one short
+
+	o, err := ParallelUpload(context.Background(), b, &ParallelUploadRequest{
+		Contents:    bytes.NewReader(contents),
+		DstName:     "dst",
+		TempPrefix:  ".tmp/upload",
+		PartSize:    partSize,
+		Concurrency: 3,
+	})
+	if err != nil {
+		t.Fatalf("ParallelUpload: %v", err)
+	}
+
+	if o.Name != "dst" {
+		t.Errorf("Name = %q, want %q", o.Name, "dst")
+	}
+	if o.Size != uint64(len(contents)) {
+		t.Errorf("Size = %d, want %d", o.Size, len(contents))
+	}
+
+	got, ok := b.data("dst")
+	if !ok {
+		t.Fatalf("destination object %q was not created", "dst")
+	}
+	if !bytes.Equal(got, contents) {
+		t.Errorf("destination contents = %q, want %q", got, contents)
+	}
+
+	// Every temporary part and intermediate object should have been cleaned
+	// up, leaving only the destination behind.
+	if names := b.names(); len(names) != 1 || names[0] != "dst" {
+		t.Errorf("objects remaining after upload = %v, want only [dst]", names)
+	}
+}
+
+func TestParallelUpload_ComposeTreeBatchesOverThirtyTwoSources(t *testing.T) {
+	b := newFakeBucket()
+
+	const partSize = 1
+	contents := make([]byte, 40) // 40 parts, forcing a second compose level
+	for i := range contents {
+		contents[i] = byte(i)
+	}
+
+	o, err := ParallelUpload(context.Background(), b, &ParallelUploadRequest{
+		Contents:    bytes.NewReader(contents),
+		DstName:     "dst",
+		TempPrefix:  ".tmp/upload",
+		PartSize:    partSize,
+		Concurrency: 8,
+	})
+	if err != nil {
+		t.Fatalf("ParallelUpload: %v", err)
+	}
+
+	got, ok := b.data("dst")
+	if !ok {
+		t.Fatalf("destination object %q was not created", "dst")
+	}
+	if !bytes.Equal(got, contents) {
+		t.Errorf("destination contents = %v, want %v (order not preserved across the compose tree)", got, contents)
+	}
+	if o.Size != uint64(len(contents)) {
+		t.Errorf("Size = %d, want %d", o.Size, len(contents))
+	}
+}
+
+func TestParallelUpload_PartFailureCleansUpAndReportsOffset(t *testing.T) {
+	b := newFakeBucket()
+
+	const partSize = 4
+	contents := []byte("0123456789abcdef") // 4 parts of 4 bytes each
+
+	failingPart := ".tmp/upload/part-0002" // offset 8
+	b.failCreate = func(name string) error {
+		if name == failingPart {
+			return errors.New("injected failure")
+		}
+		return nil
+	}
+
+	_, err := ParallelUpload(context.Background(), b, &ParallelUploadRequest{
+		Contents:    bytes.NewReader(contents),
+		DstName:     "dst",
+		TempPrefix:  ".tmp/upload",
+		PartSize:    partSize,
+		Concurrency: 1, // serialize so the failing part can't race with cleanup
+	})
+	if err == nil {
+		t.Fatalf("ParallelUpload succeeded, want an error")
+	}
+
+	var partErr *PartUploadError
+	if !errors.As(err, &partErr) {
+		t.Fatalf("err = %v (%T), want a *PartUploadError", err, err)
+	}
+	if partErr.Offset != 8 {
+		t.Errorf("Offset = %d, want 8", partErr.Offset)
+	}
+
+	// Every object created before the failure must have been cleaned up, and
+	// nothing should have been left behind under the destination name.
+	if names := b.names(); len(names) != 0 {
+		t.Errorf("objects remaining after a failed upload = %v, want none", names)
+	}
+}
@@ -59,6 +59,15 @@ type ReadObjectRequest struct {
 
 	// The generation of the object to read. Zero means the latest generation.
 	Generation int64
+
+	// The byte offset within the object at which to start reading. Must be
+	// non-negative. Zero means the beginning of the object.
+	Offset int64
+
+	// The number of bytes to read, starting at Offset. -1 means read through
+	// the end of the object. Zero (with Offset also zero) preserves the
+	// traditional whole-object read behavior.
+	Length int64
 }
 
 type StatObjectRequest struct {
@@ -133,6 +142,83 @@ type Listing struct {
 	ContinuationToken string
 }
 
+// A single source object for a call to Bucket.ComposeObjects. At least one
+// source must be supplied, and GCS allows no more than 32 per call.
+type ComposeSource struct {
+	// The name of the source object.
+	Name string
+
+	// If non-zero, the generation of the source object to use. Zero means
+	// the latest generation.
+	Generation int64
+}
+
+// A request to compose a sequence of objects into a single new object,
+// accepted by Bucket.ComposeObjects. This corresponds to the GCS "compose"
+// operation, which concatenates the contents of the sources without the
+// caller needing to download and re-upload them.
+type ComposeObjectsRequest struct {
+	// The objects to concatenate, in order. Must contain between one and 32
+	// entries.
+	Sources []ComposeSource
+
+	// The name with which to create the destination object. This field must
+	// be set. See the notes on CreateObjectRequest.Name for naming rules.
+	DstName string
+
+	// Optional information with which to create the destination object. See
+	// CreateObjectRequest for more details.
+	ContentType     string
+	ContentLanguage string
+	ContentEncoding string
+	CacheControl    string
+	Metadata        map[string]string
+
+	// If non-nil, the destination object will be created/overwritten only if
+	// its current generation matches this value. Zero means the object does
+	// not exist.
+	DstGenerationPrecondition *int64
+}
+
+// A request to copy an object, possibly to a different bucket, accepted by
+// Bucket.CopyObject. This corresponds to the GCS "copy" operation, which
+// performs the copy server-side without the caller needing to download and
+// re-upload the object.
+type CopyObjectRequest struct {
+	// The name of the source object, which lives in the bucket that CopyObject
+	// is called on.
+	SrcName string
+
+	// If non-zero, the generation of the source object to copy. Zero means
+	// the latest generation.
+	SrcGeneration int64
+
+	// If non-nil, the source object is copied only if its current generation
+	// matches this value.
+	SrcGenerationPrecondition *int64
+
+	// The name of the destination object within DstBucket. This field must be
+	// set.
+	DstName string
+
+	// The name of the bucket to copy into. If empty, defaults to the source
+	// bucket.
+	DstBucket string
+
+	// If non-nil, the destination object will be created/overwritten only if
+	// its current generation matches this value. Zero means the object does
+	// not exist.
+	DstGenerationPrecondition *int64
+
+	// Optional metadata overrides to apply to the destination object. Fields
+	// left as nil are copied unchanged from the source object.
+	ContentType     *string
+	ContentLanguage *string
+	ContentEncoding *string
+	CacheControl    *string
+	Metadata        map[string]*string
+}
+
 // A request to update the metadata of an object, accepted by
 // Bucket.UpdateObject.
 type UpdateObjectRequest struct {
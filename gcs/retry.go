@@ -0,0 +1,224 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+)
+
+// RetryPolicy controls how a bucket retries transient failures of its
+// underlying network calls.
+type RetryPolicy struct {
+	// The delay before the first retry. Subsequent retries back off
+	// exponentially from this value, capped at MaxDelay, with full jitter
+	// applied: delay = random(0, min(MaxDelay, BaseDelay * 2^attempt)).
+	//
+	// If zero, DefaultRetryPolicy's value is used.
+	BaseDelay time.Duration
+
+	// The maximum delay between attempts, regardless of how large the
+	// exponential term grows.
+	//
+	// If zero, DefaultRetryPolicy's value is used.
+	MaxDelay time.Duration
+
+	// The maximum number of attempts to make, including the first. If zero,
+	// DefaultRetryPolicy's value is used.
+	MaxAttempts int
+
+	// By default, an operation that is not known to be idempotent (e.g. a
+	// bare CreateObject call with no generation precondition) is attempted
+	// only once, since the request body may already have been partially
+	// consumed. Set AlwaysRetry to retry such operations anyway.
+	AlwaysRetry bool
+}
+
+// DefaultRetryPolicy returns the retry policy used by a bucket constructed
+// with a nil *RetryPolicy: a 1s base delay, a 32s cap, and 5 attempts.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		BaseDelay:   time.Second,
+		MaxDelay:    32 * time.Second,
+		MaxAttempts: 5,
+	}
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	d := DefaultRetryPolicy()
+	if p.BaseDelay == 0 {
+		p.BaseDelay = d.BaseDelay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = d.MaxDelay
+	}
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = d.MaxAttempts
+	}
+	return p
+}
+
+// RetryError is returned by a bucket method when an operation fails and is
+// not retried further: because every attempt has been used, because the
+// error is not transient, or because ctx was cancelled while waiting to
+// retry. It wraps the last underlying error so that callers can use
+// errors.Is and errors.As to inspect it.
+type RetryError struct {
+	// The number of attempts that were made.
+	Attempts int
+
+	// The error returned by the final attempt.
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("gcs: giving up after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// classifyError reports whether err is transient and worth retrying, along
+// with a server-requested delay (from a Retry-After header) if one was
+// supplied.
+func classifyError(err error) (retryable bool, retryAfter time.Duration) {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		switch gerr.Code {
+		case http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			retryable = true
+		}
+
+		if d, ok := parseRetryAfter(gerr.Header.Get("Retry-After")); ok {
+			retryAfter = d
+		}
+
+		return retryable, retryAfter
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Temporary() || netErr.Timeout(), 0
+	}
+
+	return false, 0
+}
+
+// parseRetryAfter parses a Retry-After header value, which may be either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// backoffDelay computes a truncated-exponential-backoff-with-full-jitter
+// delay for the given zero-based attempt number.
+func backoffDelay(policy RetryPolicy, attempt int) time.Duration {
+	maxDelay := policy.MaxDelay
+	d := policy.BaseDelay
+
+	// Compute min(maxDelay, base * 2^attempt) without risking overflow for
+	// large attempt counts.
+	for i := 0; i < attempt; i++ {
+		if d >= maxDelay {
+			d = maxDelay
+			break
+		}
+		d *= 2
+	}
+	if d > maxDelay {
+		d = maxDelay
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// withRetry calls op, retrying on transient errors according to b.retry.
+// idempotent must be false unless op is known to be safely repeatable (e.g.
+// it has no side effect on partial failure, or carries a generation
+// precondition); non-idempotent operations are attempted only once unless
+// the policy's AlwaysRetry is set.
+func (b *bucket) withRetry(ctx context.Context, idempotent bool, op func() error) error {
+	policy := b.retry.withDefaults()
+
+	maxAttempts := policy.MaxAttempts
+	if !idempotent && !policy.AlwaysRetry {
+		maxAttempts = 1
+	}
+
+	var lastErr error
+	attemptsMade := 0
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		attemptsMade = attempt + 1
+		lastErr = op()
+		if lastErr == nil {
+			return nil
+		}
+
+		if attempt == maxAttempts-1 {
+			break
+		}
+
+		retryable, retryAfter := classifyError(lastErr)
+		if !retryable {
+			break
+		}
+
+		delay := retryAfter
+		if delay == 0 {
+			delay = backoffDelay(policy, attempt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return &RetryError{Attempts: attemptsMade, Err: lastErr}
+		case <-time.After(delay):
+		}
+	}
+
+	return &RetryError{Attempts: attemptsMade, Err: lastErr}
+}
@@ -0,0 +1,154 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+)
+
+func TestWithRetry_NonRetryableStopsAtOneAttempt(t *testing.T) {
+	b := &bucket{retry: *DefaultRetryPolicy()}
+
+	calls := 0
+	permanent := &googleapi.Error{Code: http.StatusNotFound}
+	err := b.withRetry(context.Background(), true, func() error {
+		calls++
+		return permanent
+	})
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("withRetry returned %v, want a *RetryError", err)
+	}
+	if retryErr.Attempts != 1 {
+		t.Errorf("Attempts = %d, want 1 (only one call should have been made)", retryErr.Attempts)
+	}
+	if calls != 1 {
+		t.Errorf("op was called %d times, want 1", calls)
+	}
+	if !errors.Is(err, permanent) {
+		t.Errorf("errors.Is(err, permanent) = false, want true")
+	}
+}
+
+func TestWithRetry_RetryableExhaustsAllAttempts(t *testing.T) {
+	policy := *DefaultRetryPolicy()
+	policy.MaxAttempts = 3
+	policy.BaseDelay = 0
+	policy.MaxDelay = 0
+	b := &bucket{retry: policy}
+
+	calls := 0
+	transient := &googleapi.Error{Code: http.StatusServiceUnavailable}
+	err := b.withRetry(context.Background(), true, func() error {
+		calls++
+		return transient
+	})
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("withRetry returned %v, want a *RetryError", err)
+	}
+	if retryErr.Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3", retryErr.Attempts)
+	}
+	if calls != 3 {
+		t.Errorf("op was called %d times, want 3", calls)
+	}
+}
+
+func TestWithRetry_SucceedsWithoutExhaustingAttempts(t *testing.T) {
+	policy := *DefaultRetryPolicy()
+	policy.BaseDelay = 0
+	policy.MaxDelay = 0
+	b := &bucket{retry: policy}
+
+	calls := 0
+	transient := &googleapi.Error{Code: http.StatusServiceUnavailable}
+	err := b.withRetry(context.Background(), true, func() error {
+		calls++
+		if calls < 2 {
+			return transient
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("withRetry: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("op was called %d times, want 2", calls)
+	}
+}
+
+func TestWithRetry_NonIdempotentTriesOnce(t *testing.T) {
+	b := &bucket{retry: *DefaultRetryPolicy()}
+
+	calls := 0
+	transient := &googleapi.Error{Code: http.StatusServiceUnavailable}
+	err := b.withRetry(context.Background(), false, func() error {
+		calls++
+		return transient
+	})
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("withRetry returned %v, want a *RetryError", err)
+	}
+	if retryErr.Attempts != 1 {
+		t.Errorf("Attempts = %d, want 1", retryErr.Attempts)
+	}
+	if calls != 1 {
+		t.Errorf("op was called %d times, want 1", calls)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"service unavailable", &googleapi.Error{Code: http.StatusServiceUnavailable}, true},
+		{"too many requests", &googleapi.Error{Code: http.StatusTooManyRequests}, true},
+		{"not found", &googleapi.Error{Code: http.StatusNotFound}, false},
+		{"bad request", &googleapi.Error{Code: http.StatusBadRequest}, false},
+		{"generic error", errors.New("boom"), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			retryable, _ := classifyError(c.err)
+			if retryable != c.retryable {
+				t.Errorf("classifyError(%v) retryable = %v, want %v", c.err, retryable, c.retryable)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay_NeverExceedsMaxDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 1, MaxDelay: 100, MaxAttempts: 5}
+	for attempt := 0; attempt < 20; attempt++ {
+		d := backoffDelay(policy, attempt)
+		if d > policy.MaxDelay {
+			t.Fatalf("backoffDelay(attempt=%d) = %d, want <= %d", attempt, d, policy.MaxDelay)
+		}
+	}
+}
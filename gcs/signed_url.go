@@ -0,0 +1,381 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeNow stands in for time.Now, overridden in tests so that fixed
+// timestamps produce reproducible signatures.
+var timeNow = time.Now
+
+// A Signer computes a raw RSA-PKCS1v15/SHA-256 signature over data. It
+// allows callers on GCE to delegate signing to the IAM signBlob API instead
+// of holding a private key locally.
+type Signer func(data []byte) ([]byte, error)
+
+// SigningKey is the key material used to sign a SignedURLOptions request. Use
+// ParseRSAPrivateKey to build one from a PEM-encoded private key, or
+// SignerSigningKey to delegate to a pluggable Signer.
+type SigningKey struct {
+	rsaKey *rsa.PrivateKey
+	signer Signer
+}
+
+// ParseRSAPrivateKey builds a SigningKey from a PEM-encoded RSA private key,
+// in either PKCS#1 or PKCS#8 form.
+func ParseRSAPrivateKey(pemData []byte) (SigningKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return SigningKey{}, errors.New("gcs: no PEM block found in private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return SigningKey{rsaKey: key}, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("gcs: parsing private key: %v", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return SigningKey{}, errors.New("gcs: private key is not an RSA key")
+	}
+
+	return SigningKey{rsaKey: rsaKey}, nil
+}
+
+// SignerSigningKey builds a SigningKey that delegates to f rather than
+// signing locally, e.g. to call the IAM projects.serviceAccounts.signBlob
+// API.
+func SignerSigningKey(f Signer) SigningKey {
+	return SigningKey{signer: f}
+}
+
+func (k SigningKey) sign(data []byte) ([]byte, error) {
+	if k.signer != nil {
+		return k.signer(data)
+	}
+
+	if k.rsaKey == nil {
+		return nil, errors.New("gcs: SigningKey has neither a private key nor a Signer")
+	}
+
+	hashed := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, k.rsaKey, crypto.SHA256, hashed[:])
+}
+
+// SignedURLScheme selects between the two GCS signed URL schemes supported
+// by SignedURLOptions.
+type SignedURLScheme int
+
+const (
+	// SignedURLV4 produces a V4 signed URL (the current, recommended
+	// scheme). This is the default.
+	SignedURLV4 SignedURLScheme = iota
+
+	// SignedURLV2 produces a V2 signed URL, for compatibility with callers
+	// that still depend on it.
+	SignedURLV2
+)
+
+// SignedURLOptions controls the URL produced by Bucket.SignedURL.
+type SignedURLOptions struct {
+	// The HTTP method the signed URL will be used with, e.g. "GET", "PUT",
+	// "DELETE", or "HEAD". Required.
+	Method string
+
+	// The absolute time at which the signed URL should stop working. Exactly
+	// one of Expires and Expiry must be set.
+	Expires time.Time
+
+	// The duration, from the time SignedURL is called, for which the signed
+	// URL should work. Exactly one of Expires and Expiry must be set.
+	Expiry time.Duration
+
+	// If the client will send a Content-Type header, it must be supplied
+	// here so that it is covered by the signature.
+	ContentType string
+
+	// If the client will send a Content-MD5 header, it must be supplied here
+	// (base64-encoded) so that it is covered by the signature.
+	MD5 string
+
+	// Additional headers the client will send that must be covered by the
+	// signature, keyed by header name.
+	Headers map[string]string
+
+	// Additional query parameters to include on the signed URL, e.g.
+	// "userProject" or "response-content-disposition".
+	QueryParameters map[string]string
+
+	// The email address of the service account (or other principal) that
+	// owns SigningKey. Required.
+	GoogleAccessID string
+
+	// The key material to sign with. Required.
+	SigningKey SigningKey
+
+	// Which signing scheme to use. The zero value is SignedURLV4.
+	Scheme SignedURLScheme
+}
+
+func (b *bucket) SignedURL(objectName string, opts *SignedURLOptions) (string, error) {
+	if opts.Method == "" {
+		return "", errors.New("gcs: SignedURLOptions.Method must be set")
+	}
+
+	if opts.GoogleAccessID == "" {
+		return "", errors.New("gcs: SignedURLOptions.GoogleAccessID must be set")
+	}
+
+	switch opts.Scheme {
+	case SignedURLV2:
+		return signedURLV2(b.name, objectName, opts)
+
+	default:
+		return signedURLV4(b.name, objectName, opts)
+	}
+}
+
+// resolveExpiry returns the absolute expiry time implied by opts, relative
+// to now.
+func resolveExpiry(opts *SignedURLOptions, now time.Time) (time.Time, error) {
+	switch {
+	case !opts.Expires.IsZero() && opts.Expiry != 0:
+		return time.Time{}, errors.New("gcs: at most one of Expires and Expiry may be set")
+
+	case !opts.Expires.IsZero():
+		return opts.Expires, nil
+
+	case opts.Expiry > 0:
+		return now.Add(opts.Expiry), nil
+
+	default:
+		return time.Time{}, errors.New("gcs: one of Expires and Expiry must be set")
+	}
+}
+
+// signedHeaderMap returns the lowercased header names and values that must
+// be covered by the signature, always including Host.
+func signedHeaderMap(opts *SignedURLOptions, host string) map[string]string {
+	headers := map[string]string{
+		"host": host,
+	}
+
+	for k, v := range opts.Headers {
+		headers[strings.ToLower(k)] = v
+	}
+	if opts.ContentType != "" {
+		headers["content-type"] = opts.ContentType
+	}
+	if opts.MD5 != "" {
+		headers["content-md5"] = opts.MD5
+	}
+
+	return headers
+}
+
+// rfc3986Escape percent-encodes s using the RFC 3986 rules required by the
+// canonical query string, where Go's url.QueryEscape would otherwise encode
+// spaces as "+".
+func rfc3986Escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+// escapedResourcePath renders bucketName and objectName as a "/"-prefixed
+// path, percent-encoding each "/"-separated segment per RFC 3986 but leaving
+// the "/" separators themselves unescaped. Object names may contain
+// characters like spaces or "#" that are not valid unescaped in a URL path,
+// so this must be used anywhere bucketName/objectName are embedded in a
+// signed URL or its canonical request, rather than interpolating them
+// directly.
+func escapedResourcePath(bucketName, objectName string) string {
+	segments := strings.Split(bucketName+"/"+objectName, "/")
+	for i, s := range segments {
+		segments[i] = rfc3986Escape(s)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// canonicalQueryString renders v as a sorted, RFC-3986-escaped query string.
+func canonicalQueryString(v url.Values) string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, val := range v[k] {
+			parts = append(parts, rfc3986Escape(k)+"="+rfc3986Escape(val))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+const goog4SigningAlgorithm = "GOOG4-RSA-SHA256"
+
+// signedURLV4 implements the V4 signing scheme documented at
+// https://cloud.google.com/storage/docs/access-control/signed-urls-v4
+func signedURLV4(bucketName, objectName string, opts *SignedURLOptions) (string, error) {
+	const host = "storage.googleapis.com"
+
+	now := timeNow().UTC()
+	expires, err := resolveExpiry(opts, now)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := expires.Sub(now)
+	if ttl <= 0 {
+		return "", errors.New("gcs: signed URL expiry must be in the future")
+	}
+	if ttl > 7*24*time.Hour {
+		return "", errors.New("gcs: V4 signed URLs cannot be valid for more than 7 days")
+	}
+
+	timestamp := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/auto/storage/goog4_request", date)
+	credential := fmt.Sprintf("%s/%s", opts.GoogleAccessID, scope)
+
+	headers := signedHeaderMap(opts, host)
+	signedHeaderNames := make([]string, 0, len(headers))
+	for k := range headers {
+		signedHeaderNames = append(signedHeaderNames, k)
+	}
+	sort.Strings(signedHeaderNames)
+
+	var canonicalHeaders bytes.Buffer
+	for _, k := range signedHeaderNames {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", k, strings.TrimSpace(headers[k]))
+	}
+	signedHeaders := strings.Join(signedHeaderNames, ";")
+
+	query := url.Values{}
+	for k, v := range opts.QueryParameters {
+		query.Set(k, v)
+	}
+	query.Set("X-Goog-Algorithm", goog4SigningAlgorithm)
+	query.Set("X-Goog-Credential", credential)
+	query.Set("X-Goog-Date", timestamp)
+	query.Set("X-Goog-Expires", strconv.FormatInt(int64(ttl.Seconds()), 10))
+	query.Set("X-Goog-SignedHeaders", signedHeaders)
+
+	qs := canonicalQueryString(query)
+	path := escapedResourcePath(bucketName, objectName)
+
+	// No request body hash was supplied by the caller, so the payload is
+	// unsigned; only the metadata above is covered by the signature.
+	const payloadHash = "UNSIGNED-PAYLOAD"
+
+	canonicalRequest := strings.Join([]string{
+		opts.Method,
+		path,
+		qs,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		goog4SigningAlgorithm,
+		timestamp,
+		scope,
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+
+	sig, err := opts.SigningKey.sign([]byte(stringToSign))
+	if err != nil {
+		return "", fmt.Errorf("gcs: signing: %v", err)
+	}
+
+	return fmt.Sprintf(
+		"https://%s%s?%s&X-Goog-Signature=%s",
+		host,
+		path,
+		qs,
+		hex.EncodeToString(sig)), nil
+}
+
+// signedURLV2 implements the older V2 signing scheme.
+func signedURLV2(bucketName, objectName string, opts *SignedURLOptions) (string, error) {
+	now := timeNow()
+	expires, err := resolveExpiry(opts, now)
+	if err != nil {
+		return "", err
+	}
+
+	resource := escapedResourcePath(bucketName, objectName)
+
+	headerNames := make([]string, 0, len(opts.Headers))
+	lowered := make(map[string]string, len(opts.Headers))
+	for k, v := range opts.Headers {
+		lk := strings.ToLower(k)
+		lowered[lk] = v
+		headerNames = append(headerNames, lk)
+	}
+	sort.Strings(headerNames)
+
+	var extensionHeaders bytes.Buffer
+	for _, k := range headerNames {
+		fmt.Fprintf(&extensionHeaders, "%s:%s\n", k, lowered[k])
+	}
+
+	stringToSign := strings.Join([]string{
+		opts.Method,
+		opts.MD5,
+		opts.ContentType,
+		strconv.FormatInt(expires.Unix(), 10),
+		extensionHeaders.String() + resource,
+	}, "\n")
+
+	sig, err := opts.SigningKey.sign([]byte(stringToSign))
+	if err != nil {
+		return "", fmt.Errorf("gcs: signing: %v", err)
+	}
+
+	query := url.Values{}
+	for k, v := range opts.QueryParameters {
+		query.Set(k, v)
+	}
+	query.Set("GoogleAccessId", opts.GoogleAccessID)
+	query.Set("Expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("Signature", base64.StdEncoding.EncodeToString(sig))
+
+	return fmt.Sprintf("https://storage.googleapis.com%s?%s", resource, query.Encode()), nil
+}